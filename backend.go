@@ -1,19 +1,18 @@
 package main
 
 import (
-	"bytes"
-	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"time"
 
-	// --- NEW IMPORT ---
-	// Import the official Go trace parsing library.
+	"github.com/Shuhaib123/ToolProject/graph/export"
+	"github.com/Shuhaib123/ToolProject/sandbox"
+	"github.com/Shuhaib123/ToolProject/store"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/trace"
 )
 
@@ -37,61 +36,88 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 }
 
 func main() {
+	s, err := store.Open("traces.db")
+	if err != nil {
+		log.WithError(err).Warn("trace cache disabled")
+	} else {
+		traceStore = s
+	}
+
 	http.HandleFunc("/", rootHandler)
 	http.HandleFunc("/trace", withCORS(traceHandler))
-	println("Go Visualizer server starting on http://localhost:8080")
+	http.HandleFunc("/trace/stream", withCORS(streamTraceHandler))
+	http.HandleFunc("/trace/", withCORS(traceByHashHandler))
+	http.HandleFunc("/history", withCORS(historyHandler))
+	http.Handle("/metrics", promhttp.Handler())
+	log.Info("Go Visualizer server starting on http://localhost:8080")
 	http.ListenAndServe(":8080", nil)
 }
 
 func traceHandler(w http.ResponseWriter, r *http.Request) {
+	requestID := nextRequestID()
+	reqLog := log.WithField("request_id", requestID)
+
 	code, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, "Failed to read code", 400)
 		return
 	}
+	reqLog = reqLog.WithField("source_bytes", len(code))
 
-	dir, _ := ioutil.TempDir("", "gtrace")
-	defer os.RemoveAll(dir)
-	tmpFile := filepath.Join(dir, "main.go")
-	if err := ioutil.WriteFile(tmpFile, code, 0644); err != nil {
-		http.Error(w, "Failed to write temp file", 500)
-		return
+	hash := store.Hash(code)
+	if traceStore != nil {
+		if _, graphJSON, ok, err := traceStore.Get(hash); err == nil && ok {
+			cacheHitsTotal.Inc()
+			reqLog.Info("served from cache")
+			serveCachedGraph(w, r, graphJSON)
+			return
+		}
 	}
 
-	// Use a context with a timeout to prevent long-running programs from hanging the server.
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	cmd := exec.CommandContext(ctx, "go", "run", tmpFile)
-	cmd.Dir = dir
-	output, _ := cmd.CombinedOutput()
+	dir, _ := ioutil.TempDir("", "gtrace")
+	defer os.RemoveAll(dir)
 
-	// Check if the command was killed due to the timeout.
-	if ctx.Err() == context.DeadlineExceeded {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":         "Execution timed out after 5 seconds.",
-			"go_run_output": "This often happens with long-running servers or programs with infinite loops. Please ensure your program terminates to generate a complete trace.",
-		})
-		return
-	}
+	// Compile and run the submission inside the sandbox: disallowed imports
+	// are rejected before compilation, and the binary executes as an
+	// unprivileged user with no network access and its own compile/run
+	// timeouts, rather than directly on the host.
+	result, err := sandbox.Run(r.Context(), dir, code, sandbox.DefaultConfig())
+	if err != nil {
+		var sbErr *sandbox.Error
+		if errors.As(err, &sbErr) {
+			switch sbErr.Kind {
+			case sandbox.Timeout:
+				timeoutsTotal.Inc()
+			case sandbox.BuildError:
+				compileFailuresTotal.Inc()
+			case sandbox.RuntimeError:
+				runtimeFailuresTotal.Inc()
+			}
+			reqLog.WithField("kind", sbErr.Kind).Warn("sandboxed run failed")
 
-	tracePath := filepath.Join(dir, "trace.out")
-	if _, err := os.Stat(tracePath); os.IsNotExist(err) {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(500)
-		json.NewEncoder(w).Encode(map[string]interface{}{
-			"error":         "trace.out not generated",
-			"go_run_output": string(output),
-			"run_error":     "This can happen if there was a compile error in the code.",
-		})
+			status := http.StatusBadRequest
+			if sbErr.Kind == sandbox.RuntimeError {
+				status = http.StatusInternalServerError
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":         string(sbErr.Kind),
+				"go_run_output": sbErr.Output,
+			})
+			return
+		}
+		reqLog.WithError(err).Error("sandboxed run failed")
+		http.Error(w, "Failed to run sandboxed program", 500)
 		return
 	}
+	compileDuration.Observe(result.CompileTime.Seconds())
+	runDuration.Observe(result.RunTime.Seconds())
 
 	// --- MODIFICATION: Call the new analyzeTrace function ---
-	jsonData, err := analyzeTrace(tracePath)
+	jsonData, parseTime, err := timeParse(result.TracePath)
 	if err != nil {
+		reqLog.WithError(err).Error("failed to analyze trace.out")
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(500)
 		json.NewEncoder(w).Encode(map[string]interface{}{
@@ -100,11 +126,145 @@ func traceHandler(w http.ResponseWriter, r *http.Request) {
 		})
 		return
 	}
+	traceParseDuration.Observe(parseTime.Seconds())
+
+	var traceBytes []byte
+	if traceBytes, err = ioutil.ReadFile(result.TracePath); err == nil {
+		cacheTrace(hash, traceBytes, jsonData)
+	}
+
+	graph, _ := jsonData["trace"].(Graph)
+	goroutineCount := 0
+	for _, n := range graph.Nodes {
+		if n.Type == "goroutine" {
+			goroutineCount++
+		}
+	}
+	reqLog.WithFields(logrus.Fields{
+		"compile_ms":  result.CompileTime.Milliseconds(),
+		"run_ms":      result.RunTime.Milliseconds(),
+		"goroutines":  goroutineCount,
+		"events":      jsonData["event_count"],
+		"trace_bytes": len(traceBytes),
+	}).Info("trace analyzed")
+
+	// A `?format=` query parameter selects an alternate export format
+	// (Graphviz DOT, Chrome trace_event JSON, Mermaid) instead of the
+	// default bespoke {nodes, edges} JSON.
+	if format := r.URL.Query().Get("format"); format != "" {
+		writeExported(w, graph, format)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(jsonData)
 }
 
+// writeExported encodes g using the exporter registered for format and
+// writes it to w, or reports an error if format is unrecognized.
+func writeExported(w http.ResponseWriter, g Graph, format string) {
+	exporter, err := export.ForFormat(format)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ct, ok := exporter.(export.ContentType); ok {
+		w.Header().Set("Content-Type", ct.ContentType())
+	}
+	if err := exporter.Encode(w, toExportGraph(g)); err != nil {
+		http.Error(w, fmt.Sprintf("failed to encode %s: %v", format, err), http.StatusInternalServerError)
+	}
+}
+
+// toExportGraph converts the backend's internal Graph into the decoupled
+// shape graph/export operates on.
+func toExportGraph(g Graph) export.Graph {
+	eg := export.Graph{
+		Nodes: make([]export.Node, len(g.Nodes)),
+		Edges: make([]export.Edge, len(g.Edges)),
+	}
+	for i, n := range g.Nodes {
+		eg.Nodes[i] = export.Node{ID: n.ID, Label: n.Label, Type: n.Type, State: n.State}
+	}
+	for i, e := range g.Edges {
+		eg.Edges[i] = export.Edge{From: e.From, To: e.To, Kind: e.Kind, Label: e.Label}
+	}
+	return eg
+}
+
+// GoroutineStats aggregates the time a goroutine spent in each scheduling
+// state, derived from the timestamps between its state-transition events,
+// plus how many times the scheduler forcibly preempted it (as opposed to it
+// yielding the P voluntarily by blocking).
+type GoroutineStats struct {
+	RunningNS   int64 `json:"running_ns"`
+	BlockedNS   int64 `json:"blocked_ns"`
+	SyscallNS   int64 `json:"syscall_ns"`
+	Preemptions int   `json:"preemptions"`
+}
+
+// Node is a vertex in the concurrency graph: a goroutine, a syscall, a GC
+// phase, or a P (logical processor).
+type Node struct {
+	ID    uint64          `json:"id"`
+	Label string          `json:"label"`
+	Type  string          `json:"type"`
+	State string          `json:"state"`
+	Stats *GoroutineStats `json:"stats,omitempty"`
+}
+
+// Edge is a directed relationship between nodes: goroutine creation, a
+// goroutine blocking on a channel send/receive (a self-edge), one goroutine
+// unblocking another, or a goroutine entering a syscall.
+type Edge struct {
+	From  uint64 `json:"from"`
+	To    uint64 `json:"to"`
+	Kind  string `json:"kind"`
+	Label string `json:"label,omitempty"`
+}
+
+// Graph is the full concurrency picture handed back to the frontend.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// goroutineState tracks the bookkeeping needed to turn a stream of
+// state-transition events into aggregated GoroutineStats: the state the
+// goroutine is currently in, and the timestamp it entered that state.
+type goroutineState struct {
+	node        *Node
+	lastState   string
+	lastStateTs int64
+}
+
+func (g *goroutineState) enter(state string, ts int64) {
+	g.accrue(ts)
+	g.lastState = state
+	g.lastStateTs = ts
+	g.node.State = state
+}
+
+// accrue adds the time spent in the current state (up to ts) to the
+// matching stats bucket.
+func (g *goroutineState) accrue(ts int64) {
+	if g.lastStateTs == 0 {
+		return
+	}
+	d := ts - g.lastStateTs
+	if d < 0 {
+		return
+	}
+	switch g.lastState {
+	case "running":
+		g.node.Stats.RunningNS += d
+	case "blocked":
+		g.node.Stats.BlockedNS += d
+	case "syscall":
+		g.node.Stats.SyscallNS += d
+	}
+}
+
 // --- NEW analyzeTrace function ---
 // This function now uses the golang.org/x/trace library directly.
 func analyzeTrace(tracePath string) (map[string]interface{}, error) {
@@ -121,69 +281,153 @@ func analyzeTrace(tracePath string) (map[string]interface{}, error) {
 		return nil, fmt.Errorf("failed to parse trace data: %w", err)
 	}
 
-	// 3. Define structs to hold our simplified graph data.
-	// This matches what the frontend JavaScript expects.
-	type Node struct {
-		ID    uint64 `json:"id"`
-		Label string `json:"label"`
-		Type  string `json:"type"`
-		State string `json:"state"`
-	}
-	type Edge struct {
-		From uint64 `json:"from"`
-		To   uint64 `json:"to"`
-	}
-	type Graph struct {
-		Nodes []Node `json:"nodes"`
-		Edges []Edge `json:"edges"`
-	}
-
 	graph := Graph{
 		Nodes: make([]Node, 0),
 		Edges: make([]Edge, 0),
 	}
 
-	// 4. Loop through all events and build our graph structure.
-	goroutines := make(map[uint64]*Node)
-	// Ensure the main goroutine (ID 1) always exists.
-	goroutines[1] = &Node{ID: 1, Label: "goroutine 1 (main)", Type: "goroutine", State: "running"}
+	goroutines := make(map[uint64]*goroutineState)
+	getGoroutine := func(id uint64) *goroutineState {
+		gs, ok := goroutines[id]
+		if !ok {
+			label := fmt.Sprintf("goroutine %d", id)
+			if id == 1 {
+				label = "goroutine 1 (main)"
+			}
+			gs = &goroutineState{node: &Node{ID: id, Label: label, Type: "goroutine", State: "created", Stats: &GoroutineStats{}}}
+			goroutines[id] = gs
+		}
+		return gs
+	}
+	getGoroutine(1) // Ensure the main goroutine always exists.
+
+	// syscalls and gcEvents hold the non-goroutine nodes (syscalls, GC
+	// phases, STW pauses), each given a synthetic ID from a counter that
+	// starts above any real goroutine ID, since the trace format has no
+	// stable ID for them.
+	var syscalls []Node
+	var gcEvents []Node
+	var procs []Node
+	gcStarts := make(map[string]int64) // phase -> start ts, for duration math
+
+	// openGCIdx/openSTWIdx point at the still-running GC-cycle/STW node in
+	// gcEvents, if any. Real traces nest STW inside a GC cycle
+	// (GCStart -> STWStart -> STWDone -> ... -> GCDone), so the two phases
+	// need their own "current open node" rather than both patching
+	// gcEvents[len(gcEvents)-1]: by the time GCDone fires, the last element
+	// would be the already-closed STW node, not the still-open GC node.
+	openGCIdx, openSTWIdx := -1, -1
 
+	nextSyntheticID := uint64(1 << 40) // well above any real goroutine ID
+	newSynthetic := func() uint64 {
+		id := nextSyntheticID
+		nextSyntheticID++
+		return id
+	}
+
+	// 3. Loop through all events and build the richer graph structure.
 	for _, ev := range result.Events {
 		switch ev.Type {
 		case trace.EvGoCreate:
-			// A new goroutine was created.
 			childID := ev.Args[0]
 			parentID := ev.G
-			if _, ok := goroutines[parentID]; !ok {
-				goroutines[parentID] = &Node{ID: parentID, Label: fmt.Sprintf("goroutine %d", parentID), Type: "goroutine", State: "created"}
+			getGoroutine(parentID)
+			getGoroutine(childID)
+			graph.Edges = append(graph.Edges, Edge{From: parentID, To: childID, Kind: "create"})
+
+		case trace.EvGoStart:
+			getGoroutine(ev.G).enter("running", ev.Ts)
+
+		case trace.EvGoEnd:
+			getGoroutine(ev.G).enter("finished", ev.Ts)
+
+		case trace.EvGoBlockSend, trace.EvGoBlockRecv:
+			// The trace format carries no channel address for these events
+			// (only an unexposed stack ID), so the edge can say what kind of
+			// op blocked but can't name which channel.
+			gs := getGoroutine(ev.G)
+			gs.enter("blocked", ev.Ts)
+			kind := "chan_send"
+			if ev.Type == trace.EvGoBlockRecv {
+				kind = "chan_recv"
 			}
-			if _, ok := goroutines[childID]; !ok {
-				goroutines[childID] = &Node{ID: childID, Label: fmt.Sprintf("goroutine %d", childID), Type: "goroutine", State: "created"}
+			graph.Edges = append(graph.Edges, Edge{From: ev.G, To: ev.G, Kind: kind})
+
+		case trace.EvGoUnblock:
+			unblockedID := ev.Args[0]
+			getGoroutine(unblockedID).enter("runnable", ev.Ts)
+			graph.Edges = append(graph.Edges, Edge{From: ev.G, To: unblockedID, Kind: "unblock"})
+
+		case trace.EvGoBlockSync:
+			getGoroutine(ev.G).enter("blocked", ev.Ts)
+
+		case trace.EvGoSysCall:
+			getGoroutine(ev.G)
+			sysID := newSynthetic()
+			syscalls = append(syscalls, Node{ID: sysID, Label: fmt.Sprintf("syscall (g%d)", ev.G), Type: "syscall", State: "running"})
+			graph.Edges = append(graph.Edges, Edge{From: ev.G, To: sysID, Kind: "syscall"})
+
+		case trace.EvGoSysBlock:
+			getGoroutine(ev.G).enter("syscall", ev.Ts)
+
+		case trace.EvGoSysExit:
+			getGoroutine(ev.G).enter("runnable", ev.Ts)
+
+		case trace.EvGoPreempt:
+			gs := getGoroutine(ev.G)
+			gs.enter("runnable", ev.Ts)
+			gs.node.Stats.Preemptions++
+
+		case trace.EvGCStart:
+			gcStarts["gc"] = ev.Ts
+			id := newSynthetic()
+			gcEvents = append(gcEvents, Node{ID: id, Label: "GC cycle", Type: "gc", State: "running"})
+			openGCIdx = len(gcEvents) - 1
+
+		case trace.EvGCDone:
+			if openGCIdx >= 0 {
+				gcEvents[openGCIdx].State = "done"
+				gcEvents[openGCIdx].Label = fmt.Sprintf("GC cycle (%dns)", ev.Ts-gcStarts["gc"])
+				openGCIdx = -1
 			}
-			graph.Edges = append(graph.Edges, Edge{From: parentID, To: childID})
 
-		case trace.EvGoStart:
-			// A goroutine started running.
-			if g, ok := goroutines[ev.G]; ok {
-				g.State = "running"
+		case trace.EvGCSTWStart:
+			gcStarts["stw"] = ev.Ts
+			id := newSynthetic()
+			gcEvents = append(gcEvents, Node{ID: id, Label: "GC STW", Type: "gc_stw", State: "running"})
+			openSTWIdx = len(gcEvents) - 1
+
+		case trace.EvGCSTWDone:
+			if openSTWIdx >= 0 {
+				gcEvents[openSTWIdx].State = "done"
+				gcEvents[openSTWIdx].Label = fmt.Sprintf("GC STW (%dns)", ev.Ts-gcStarts["stw"])
+				openSTWIdx = -1
 			}
-		case trace.EvGoEnd:
-			// A goroutine finished.
-			if g, ok := goroutines[ev.G]; ok {
-				g.State = "finished"
+
+		case trace.EvProcStart:
+			procs = append(procs, Node{ID: newSynthetic(), Label: fmt.Sprintf("proc %d", ev.P), Type: "proc", State: "running"})
+
+		case trace.EvProcStop:
+			if len(procs) > 0 {
+				procs[len(procs)-1].State = "stopped"
 			}
 		}
 	}
 
-	// 5. Add all found goroutines to the final nodes list.
-	for _, node := range goroutines {
-		graph.Nodes = append(graph.Nodes, *node)
+	// 4. Add all found goroutines, with their final aggregated stats, to
+	// the nodes list, alongside the syscall/GC/proc nodes discovered above.
+	for _, gs := range goroutines {
+		graph.Nodes = append(graph.Nodes, *gs.node)
 	}
+	graph.Nodes = append(graph.Nodes, syscalls...)
+	graph.Nodes = append(graph.Nodes, gcEvents...)
+	graph.Nodes = append(graph.Nodes, procs...)
 
-	// 6. Wrap the graph in a map to match the frontend's expectation.
+	// 5. Wrap the graph in a map to match the frontend's expectation.
 	// The frontend JavaScript's `convertTraceToGraph` function is no longer needed
 	// because we are doing the conversion here on the backend.
 	return map[string]interface{}{
-		"trace": graph, // The key is "trace", but the value is our new Graph struct
+		"trace":       graph, // The key is "trace", but the value is our new Graph struct
+		"event_count": len(result.Events),
 	}, nil
 }
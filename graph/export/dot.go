@@ -0,0 +1,51 @@
+package export
+
+import (
+	"fmt"
+	"io"
+)
+
+// DOTExporter renders a Graph as Graphviz DOT, so it can be piped into
+// `dot -Tsvg` or opened directly in any Graphviz-aware viewer.
+type DOTExporter struct{}
+
+func (DOTExporter) ContentType() string { return "text/vnd.graphviz" }
+
+func (DOTExporter) Encode(w io.Writer, g Graph) error {
+	if _, err := fmt.Fprintln(w, "digraph trace {"); err != nil {
+		return err
+	}
+	for _, n := range g.Nodes {
+		if _, err := fmt.Fprintf(w, "  %d [label=%q, shape=%s];\n", n.ID, fmt.Sprintf("%s\n%s", n.Label, n.State), dotShape(n.Type)); err != nil {
+			return err
+		}
+	}
+	for _, e := range g.Edges {
+		label := e.Kind
+		if e.Label != "" {
+			label = fmt.Sprintf("%s: %s", e.Kind, e.Label)
+		}
+		if _, err := fmt.Fprintf(w, "  %d -> %d [label=%q];\n", e.From, e.To, label); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+// dotShape picks a Graphviz node shape that hints at the node's role in the
+// graph (goroutine vs. syscall vs. GC phase).
+func dotShape(nodeType string) string {
+	switch nodeType {
+	case "goroutine":
+		return "ellipse"
+	case "syscall":
+		return "box"
+	case "gc", "gc_stw":
+		return "hexagon"
+	case "proc":
+		return "diamond"
+	default:
+		return "ellipse"
+	}
+}
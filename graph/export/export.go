@@ -0,0 +1,58 @@
+// Package export converts a parsed concurrency graph into formats other
+// tools already know how to render: Graphviz DOT, the Chrome trace_event
+// JSON format consumed by chrome://tracing and Perfetto, and Mermaid
+// sequence diagrams.
+package export
+
+import (
+	"fmt"
+	"io"
+)
+
+// Node and Edge mirror the shape analyzeTrace produces, kept independent of
+// package main so this package has no import back on it.
+type Node struct {
+	ID    uint64
+	Label string
+	Type  string
+	State string
+}
+
+type Edge struct {
+	From  uint64
+	To    uint64
+	Kind  string
+	Label string
+}
+
+// Graph is the input every Exporter encodes.
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+}
+
+// Exporter encodes a Graph into a specific output format.
+type Exporter interface {
+	Encode(w io.Writer, g Graph) error
+}
+
+// ContentType reports the MIME type the handler should set for this
+// exporter's output.
+type ContentType interface {
+	ContentType() string
+}
+
+// ForFormat resolves the `?format=` query value to an Exporter. format is
+// case-sensitive and matches the values accepted by the /trace handler.
+func ForFormat(format string) (Exporter, error) {
+	switch format {
+	case "dot":
+		return DOTExporter{}, nil
+	case "chrome", "chrometrace":
+		return ChromeTraceExporter{}, nil
+	case "mermaid":
+		return MermaidExporter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown export format %q", format)
+	}
+}
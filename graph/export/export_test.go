@@ -0,0 +1,68 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func sampleGraph() Graph {
+	return Graph{
+		Nodes: []Node{
+			{ID: 1, Label: "goroutine 1 (main)", Type: "goroutine", State: "running"},
+			{ID: 2, Label: "goroutine 2", Type: "goroutine", State: "finished"},
+		},
+		Edges: []Edge{
+			{From: 1, To: 2, Kind: "create"},
+		},
+	}
+}
+
+func TestForFormatUnknown(t *testing.T) {
+	if _, err := ForFormat("nonsense"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+}
+
+func TestDOTExporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (DOTExporter{}).Encode(&buf, sampleGraph()); err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"digraph trace {", "1 -> 2", "}"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("DOT output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestChromeTraceExporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (ChromeTraceExporter{}).Encode(&buf, sampleGraph()); err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"traceEvents"`) {
+		t.Errorf("expected traceEvents key in output:\n%s", out)
+	}
+	if !strings.Contains(out, `"ph":"i"`) {
+		t.Errorf("expected at least one instant event:\n%s", out)
+	}
+	if !strings.Contains(out, `"ph":"s","ts":`) || !strings.Contains(out, `"id":0`) {
+		t.Errorf("expected a flow event with a top-level id field:\n%s", out)
+	}
+}
+
+func TestMermaidExporter(t *testing.T) {
+	var buf bytes.Buffer
+	if err := (MermaidExporter{}).Encode(&buf, sampleGraph()); err != nil {
+		t.Fatalf("Encode returned error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"sequenceDiagram", "participant g1", "g1->>g2: create"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Mermaid output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package export
+
+import (
+	"fmt"
+	"io"
+)
+
+// MermaidExporter renders a Graph as a Mermaid sequenceDiagram, with each
+// goroutine as a declared lifeline and each edge (creation, channel
+// send/recv, unblock, syscall) as an arrow from its source node to its
+// target node. Non-goroutine endpoints such as syscall nodes are not
+// declared as participants; Mermaid adds them implicitly.
+type MermaidExporter struct{}
+
+func (MermaidExporter) ContentType() string { return "text/plain" }
+
+func (MermaidExporter) Encode(w io.Writer, g Graph) error {
+	if _, err := fmt.Fprintln(w, "sequenceDiagram"); err != nil {
+		return err
+	}
+	for _, n := range g.Nodes {
+		if n.Type != "goroutine" {
+			continue
+		}
+		if _, err := fmt.Fprintf(w, "  participant g%d as %s\n", n.ID, n.Label); err != nil {
+			return err
+		}
+	}
+	for _, e := range g.Edges {
+		label := e.Kind
+		if e.Label != "" {
+			label = fmt.Sprintf("%s: %s", e.Kind, e.Label)
+		}
+		if _, err := fmt.Fprintf(w, "  g%d->>g%d: %s\n", e.From, e.To, label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
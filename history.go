@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/Shuhaib123/ToolProject/store"
+)
+
+// traceStore is the content-addressed cache of analyzed traces. It is
+// initialized in main; handlers treat a nil traceStore as "caching
+// disabled" rather than failing, so the server still works if the on-disk
+// store can't be opened.
+var traceStore *store.Store
+
+// traceDoc is the shape stored as each cache entry's graph JSON and
+// returned to the browser - it mirrors the map analyzeTrace produces, so a
+// cache hit or share link returns exactly what a fresh /trace response
+// would have.
+type traceDoc struct {
+	Trace      Graph `json:"trace"`
+	EventCount int   `json:"event_count"`
+}
+
+// cacheTrace saves a newly analyzed trace under the hash of the source that
+// produced it, so a repeat submission can skip compilation entirely.
+func cacheTrace(hash string, traceBytes []byte, jsonData map[string]interface{}) {
+	if traceStore == nil {
+		return
+	}
+	graph, ok := jsonData["trace"].(Graph)
+	if !ok {
+		return
+	}
+	eventCount, _ := jsonData["event_count"].(int)
+	graphJSON, err := json.Marshal(traceDoc{Trace: graph, EventCount: eventCount})
+	if err != nil {
+		return
+	}
+	goroutineCount := 0
+	for _, n := range graph.Nodes {
+		if n.Type == "goroutine" {
+			goroutineCount++
+		}
+	}
+	_ = traceStore.Put(hash, traceBytes, graphJSON, goroutineCount)
+}
+
+// serveCachedGraph decodes a cached graph JSON blob and writes it in either
+// the default JSON shape or, if `?format=` is set, one of the export
+// formats.
+func serveCachedGraph(w http.ResponseWriter, r *http.Request, graphJSON []byte) {
+	var doc traceDoc
+	if err := json.Unmarshal(graphJSON, &doc); err != nil {
+		http.Error(w, "corrupt cache entry", http.StatusInternalServerError)
+		return
+	}
+	if format := r.URL.Query().Get("format"); format != "" {
+		writeExported(w, doc.Trace, format)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(graphJSON)
+}
+
+// traceByHashHandler implements the share-link endpoint: GET /trace/{hash}
+// returns the previously cached graph for that content hash, without
+// recompiling or rerunning anything.
+func traceByHashHandler(w http.ResponseWriter, r *http.Request) {
+	hash := strings.TrimPrefix(r.URL.Path, "/trace/")
+	if hash == "" || traceStore == nil {
+		http.NotFound(w, r)
+		return
+	}
+	_, graphJSON, ok, err := traceStore.Get(hash)
+	if err != nil {
+		http.Error(w, "failed to read store", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	serveCachedGraph(w, r, graphJSON)
+}
+
+// historyHandler lists recently submitted traces so the frontend can render
+// a "history" view of past runs.
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	if traceStore == nil {
+		json.NewEncoder(w).Encode([]store.Entry{})
+		return
+	}
+	entries, err := traceStore.Recent(50)
+	if err != nil {
+		http.Error(w, "failed to read store", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
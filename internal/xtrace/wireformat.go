@@ -0,0 +1,498 @@
+package trace
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// This file ports the byte-level framing of the Go execution tracer's wire
+// format (as written by runtime/trace.Start and historically parsed by the
+// standard library's internal/trace package) far enough to recover the
+// handful of event kinds analyzeTrace cares about. It deliberately does not
+// port that parser's goroutine-causality reordering (order1005/order1007)
+// or stack symbolication - xtrace only needs a best-effort chronological
+// event stream, not a verified one, and Event has no field for call stacks.
+
+// rawEventType is the on-the-wire event type byte, distinct from EventType
+// (the smaller set of kinds this package exposes to callers).
+type rawEventType byte
+
+const (
+	rawEvNone              rawEventType = 0
+	rawEvBatch             rawEventType = 1
+	rawEvFrequency         rawEventType = 2
+	rawEvStack             rawEventType = 3
+	rawEvGomaxprocs        rawEventType = 4
+	rawEvProcStart         rawEventType = 5
+	rawEvProcStop          rawEventType = 6
+	rawEvGCStart           rawEventType = 7
+	rawEvGCDone            rawEventType = 8
+	rawEvSTWStart          rawEventType = 9
+	rawEvSTWDone           rawEventType = 10
+	rawEvGCSweepStart      rawEventType = 11
+	rawEvGCSweepDone       rawEventType = 12
+	rawEvGoCreate          rawEventType = 13
+	rawEvGoStart           rawEventType = 14
+	rawEvGoEnd             rawEventType = 15
+	rawEvGoStop            rawEventType = 16
+	rawEvGoSched           rawEventType = 17
+	rawEvGoPreempt         rawEventType = 18
+	rawEvGoSleep           rawEventType = 19
+	rawEvGoBlock           rawEventType = 20
+	rawEvGoUnblock         rawEventType = 21
+	rawEvGoBlockSend       rawEventType = 22
+	rawEvGoBlockRecv       rawEventType = 23
+	rawEvGoBlockSelect     rawEventType = 24
+	rawEvGoBlockSync       rawEventType = 25
+	rawEvGoBlockCond       rawEventType = 26
+	rawEvGoBlockNet        rawEventType = 27
+	rawEvGoSysCall         rawEventType = 28
+	rawEvGoSysExit         rawEventType = 29
+	rawEvGoSysBlock        rawEventType = 30
+	rawEvGoWaiting         rawEventType = 31
+	rawEvGoInSyscall       rawEventType = 32
+	rawEvHeapAlloc         rawEventType = 33
+	rawEvHeapGoal          rawEventType = 34
+	rawEvTimerGoroutine    rawEventType = 35
+	rawEvFutileWakeup      rawEventType = 36
+	rawEvString            rawEventType = 37
+	rawEvGoStartLocal      rawEventType = 38
+	rawEvGoUnblockLocal    rawEventType = 39
+	rawEvGoSysExitLocal    rawEventType = 40
+	rawEvGoStartLabel      rawEventType = 41
+	rawEvGoBlockGC         rawEventType = 42
+	rawEvGCMarkAssistStart rawEventType = 43
+	rawEvGCMarkAssistDone  rawEventType = 44
+	rawEvUserTaskCreate    rawEventType = 45
+	rawEvUserTaskEnd       rawEventType = 46
+	rawEvUserRegion        rawEventType = 47
+	rawEvUserLog           rawEventType = 48
+	rawEvCPUSample         rawEventType = 49
+	rawEvCount             rawEventType = 50
+)
+
+// rawEventDesc is the subset of the standard library's EventDescriptions
+// table xtrace needs: enough to validate argument counts and know whether a
+// trailing argument is a stack ID rather than a real field.
+type rawEventDesc struct {
+	name       string
+	minVersion int
+	stack      bool
+	nargs      int // number of named, non-stack, non-timestamp arguments
+}
+
+var rawEventDescs = [rawEvCount]rawEventDesc{
+	rawEvNone:              {"None", 1005, false, 0},
+	rawEvBatch:             {"Batch", 1005, false, 2},
+	rawEvFrequency:         {"Frequency", 1005, false, 1},
+	rawEvStack:             {"Stack", 1005, false, 2},
+	rawEvGomaxprocs:        {"Gomaxprocs", 1005, true, 1},
+	rawEvProcStart:         {"ProcStart", 1005, false, 1},
+	rawEvProcStop:          {"ProcStop", 1005, false, 0},
+	rawEvGCStart:           {"GCStart", 1005, true, 1},
+	rawEvGCDone:            {"GCDone", 1005, false, 0},
+	rawEvSTWStart:          {"STWStart", 1005, false, 1},
+	rawEvSTWDone:           {"STWDone", 1005, false, 0},
+	rawEvGCSweepStart:      {"GCSweepStart", 1005, true, 0},
+	rawEvGCSweepDone:       {"GCSweepDone", 1005, false, 2},
+	rawEvGoCreate:          {"GoCreate", 1005, true, 2},
+	rawEvGoStart:           {"GoStart", 1005, false, 2},
+	rawEvGoEnd:             {"GoEnd", 1005, false, 0},
+	rawEvGoStop:            {"GoStop", 1005, true, 0},
+	rawEvGoSched:           {"GoSched", 1005, true, 0},
+	rawEvGoPreempt:         {"GoPreempt", 1005, true, 0},
+	rawEvGoSleep:           {"GoSleep", 1005, true, 0},
+	rawEvGoBlock:           {"GoBlock", 1005, true, 0},
+	rawEvGoUnblock:         {"GoUnblock", 1005, true, 2},
+	rawEvGoBlockSend:       {"GoBlockSend", 1005, true, 0},
+	rawEvGoBlockRecv:       {"GoBlockRecv", 1005, true, 0},
+	rawEvGoBlockSelect:     {"GoBlockSelect", 1005, true, 0},
+	rawEvGoBlockSync:       {"GoBlockSync", 1005, true, 0},
+	rawEvGoBlockCond:       {"GoBlockCond", 1005, true, 0},
+	rawEvGoBlockNet:        {"GoBlockNet", 1005, true, 0},
+	rawEvGoSysCall:         {"GoSysCall", 1005, true, 0},
+	rawEvGoSysExit:         {"GoSysExit", 1005, false, 3},
+	rawEvGoSysBlock:        {"GoSysBlock", 1005, false, 0},
+	rawEvGoWaiting:         {"GoWaiting", 1005, false, 1},
+	rawEvGoInSyscall:       {"GoInSyscall", 1005, false, 1},
+	rawEvHeapAlloc:         {"HeapAlloc", 1005, false, 1},
+	rawEvHeapGoal:          {"HeapGoal", 1005, false, 1},
+	rawEvTimerGoroutine:    {"TimerGoroutine", 1005, false, 1},
+	rawEvFutileWakeup:      {"FutileWakeup", 1005, false, 0},
+	rawEvString:            {"String", 1007, false, 0},
+	rawEvGoStartLocal:      {"GoStartLocal", 1007, false, 1},
+	rawEvGoUnblockLocal:    {"GoUnblockLocal", 1007, true, 1},
+	rawEvGoSysExitLocal:    {"GoSysExitLocal", 1007, false, 2},
+	rawEvGoStartLabel:      {"GoStartLabel", 1008, false, 3},
+	rawEvGoBlockGC:         {"GoBlockGC", 1008, true, 0},
+	rawEvGCMarkAssistStart: {"GCMarkAssistStart", 1009, true, 0},
+	rawEvGCMarkAssistDone:  {"GCMarkAssistDone", 1009, false, 0},
+	rawEvUserTaskCreate:    {"UserTaskCreate", 1011, true, 3},
+	rawEvUserTaskEnd:       {"UserTaskEnd", 1011, true, 1},
+	rawEvUserRegion:        {"UserRegion", 1011, true, 3},
+	rawEvUserLog:           {"UserLog", 1011, true, 2},
+	rawEvCPUSample:         {"CPUSample", 1019, true, 3},
+}
+
+// supportedVersions are the trace header versions this decoder understands,
+// matching the versions internal/trace has ever shipped. Go 1.22 replaced
+// this wire format entirely; a submission compiled by a 1.22+ `go build` (the
+// version is whatever toolchain runs on the host - sandbox.Build invokes the
+// `go` on PATH, not anything inside the Docker image Run executes in) would
+// fail the version check below rather than have Parse silently misdecode it.
+var supportedVersions = map[int]bool{
+	1005: true, 1007: true, 1008: true, 1009: true,
+	1010: true, 1011: true, 1019: true, 1021: true,
+}
+
+// rawEvent is one still-undecoded record from the trace: an event type plus
+// its raw varint arguments, before timestamp deltas and per-P state have
+// been resolved into an Event.
+type rawEvent struct {
+	typ  rawEventType
+	args []uint64
+}
+
+// readRawEvents validates the trace header and reads every record into raw
+// type+argument form, reading past and discarding string dictionary records
+// as it goes. It does
+// not yet know what a "goroutine" or a "GC cycle" is - that's decodeEvents.
+func readRawEvents(r io.Reader) ([]rawEvent, int, error) {
+	var hdr [16]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, 0, fmt.Errorf("failed to read trace header: %w", err)
+	}
+	ver, err := parseHeader(hdr[:])
+	if err != nil {
+		return nil, 0, err
+	}
+	if !supportedVersions[ver] {
+		return nil, 0, fmt.Errorf("unsupported trace file version %v.%v", ver/1000, ver%1000)
+	}
+
+	var events []rawEvent
+	for {
+		var typByte [1]byte
+		if _, err := r.Read(typByte[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, 0, fmt.Errorf("failed to read event type: %w", err)
+		}
+		typ := rawEventType(typByte[0] << 2 >> 2)
+		narg := typByte[0]>>6 + 1
+		const inlineArgs = 4
+		if typ == rawEvNone || typ >= rawEvCount || rawEventDescs[typ].minVersion > ver {
+			return nil, 0, fmt.Errorf("unknown event type %v", typ)
+		}
+
+		if typ == rawEvString {
+			id, err := readVal(r)
+			if err != nil {
+				return nil, 0, err
+			}
+			if id == 0 {
+				return nil, 0, fmt.Errorf("string has invalid id 0")
+			}
+			n, err := readVal(r)
+			if err != nil {
+				return nil, 0, err
+			}
+			if n == 0 || n > 1e6 {
+				return nil, 0, fmt.Errorf("string has invalid length %d", n)
+			}
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return nil, 0, fmt.Errorf("failed to read string: %w", err)
+			}
+			continue
+		}
+
+		ev := rawEvent{typ: typ}
+		if narg < inlineArgs {
+			for i := byte(0); i < narg; i++ {
+				v, err := readVal(r)
+				if err != nil {
+					return nil, 0, fmt.Errorf("failed to read event %v argument: %w", typ, err)
+				}
+				ev.args = append(ev.args, v)
+			}
+		} else {
+			// More than inlineArgs args: the first value is the length of
+			// the event in bytes, not an argument itself.
+			evLen, err := readVal(r)
+			if err != nil {
+				return nil, 0, fmt.Errorf("failed to read event %v length: %w", typ, err)
+			}
+			var read uint64
+			for read < evLen {
+				v, n, err := readValCounted(r)
+				if err != nil {
+					return nil, 0, fmt.Errorf("failed to read event %v argument: %w", typ, err)
+				}
+				ev.args = append(ev.args, v)
+				read += uint64(n)
+			}
+			if read != evLen {
+				return nil, 0, fmt.Errorf("event %v has wrong length: want %v, got %v", typ, evLen, read)
+			}
+		}
+		if typ == rawEvUserLog {
+			// EvUserLog is followed by a value string not counted in narg.
+			n, err := readVal(r)
+			if err != nil {
+				return nil, 0, err
+			}
+			if n > 0 {
+				if n > 1e6 {
+					return nil, 0, fmt.Errorf("user log value too large: %d", n)
+				}
+				buf := make([]byte, n)
+				if _, err := io.ReadFull(r, buf); err != nil {
+					return nil, 0, fmt.Errorf("failed to read user log value: %w", err)
+				}
+			}
+		}
+		events = append(events, ev)
+	}
+	return events, ver, nil
+}
+
+// parseHeader parses a trace header of the form "go 1.7 trace\x00\x00\x00\x00"
+// and returns the parsed version as 1007.
+func parseHeader(buf []byte) (int, error) {
+	if len(buf) != 16 {
+		return 0, fmt.Errorf("bad header length")
+	}
+	if buf[0] != 'g' || buf[1] != 'o' || buf[2] != ' ' ||
+		buf[3] < '1' || buf[3] > '9' ||
+		buf[4] != '.' ||
+		buf[5] < '1' || buf[5] > '9' {
+		return 0, fmt.Errorf("not a trace file")
+	}
+	ver := int(buf[5] - '0')
+	i := 0
+	for ; i < 2 && buf[6+i] >= '0' && buf[6+i] <= '9'; i++ {
+		ver = ver*10 + int(buf[6+i]-'0')
+	}
+	ver += int(buf[3]-'0') * 1000
+	if !bytes.Equal(buf[6+i:], []byte(" trace\x00\x00\x00\x00")[:10-i]) {
+		return 0, fmt.Errorf("not a trace file")
+	}
+	return ver, nil
+}
+
+// readVal reads a base-128 varint, LSB group first.
+func readVal(r io.Reader) (uint64, error) {
+	v, _, err := readValCounted(r)
+	return v, err
+}
+
+// readValCounted is readVal plus the number of bytes consumed, which the
+// length-prefixed argument list form needs to know when to stop.
+func readValCounted(r io.Reader) (uint64, int, error) {
+	var v uint64
+	for i := 0; i < 10; i++ {
+		var b [1]byte
+		if n, err := r.Read(b[:]); err != nil || n != 1 {
+			return 0, 0, fmt.Errorf("failed to read varint: %w", err)
+		}
+		v |= uint64(b[0]&0x7f) << (uint(i) * 7)
+		if b[0]&0x80 == 0 {
+			return v, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("varint too long")
+}
+
+// argNum returns the total number of arguments a raw event carries on the
+// wire, accounting for the implicit timestamp (and, pre-1.7, sequence
+// number) every non-batch event is prefixed with.
+func argNum(typ rawEventType, ver int) int {
+	if typ == rawEvStack {
+		return -1 // variable-length; the caller skips the count check for it
+	}
+	desc := rawEventDescs[typ]
+	narg := desc.nargs
+	if desc.stack {
+		narg++
+	}
+	switch typ {
+	case rawEvBatch, rawEvFrequency, rawEvTimerGoroutine:
+		if ver < 1007 {
+			narg++ // unused arg before 1.7
+		}
+		return narg
+	}
+	narg++ // timestamp
+	if ver < 1007 {
+		narg++ // sequence
+	}
+	switch typ {
+	case rawEvGCSweepDone:
+		if ver < 1009 {
+			narg -= 2
+		}
+	case rawEvGCStart, rawEvGoStart, rawEvGoUnblock:
+		if ver < 1007 {
+			narg--
+		}
+	case rawEvSTWStart:
+		if ver < 1010 {
+			narg--
+		}
+	}
+	return narg
+}
+
+// eventTypeFor maps a wire event type to the smaller set of kinds this
+// package exposes, returning ok=false for event kinds analyzeTrace doesn't
+// consume (GC sweeps, heap stats, user tasks, ...).
+func eventTypeFor(typ rawEventType) (EventType, bool) {
+	switch typ {
+	case rawEvGoCreate:
+		return EvGoCreate, true
+	case rawEvGoStart, rawEvGoStartLocal, rawEvGoStartLabel:
+		return EvGoStart, true
+	case rawEvGoEnd:
+		return EvGoEnd, true
+	case rawEvGoBlockSend:
+		return EvGoBlockSend, true
+	case rawEvGoBlockRecv:
+		return EvGoBlockRecv, true
+	case rawEvGoUnblock, rawEvGoUnblockLocal:
+		return EvGoUnblock, true
+	case rawEvGoBlockSync:
+		return EvGoBlockSync, true
+	case rawEvGoSysCall:
+		return EvGoSysCall, true
+	case rawEvGoSysBlock:
+		return EvGoSysBlock, true
+	case rawEvGoSysExit, rawEvGoSysExitLocal:
+		return EvGoSysExit, true
+	case rawEvGCStart:
+		return EvGCStart, true
+	case rawEvGCDone:
+		return EvGCDone, true
+	case rawEvSTWStart:
+		return EvGCSTWStart, true
+	case rawEvSTWDone:
+		return EvGCSTWDone, true
+	case rawEvProcStart:
+		return EvProcStart, true
+	case rawEvProcStop:
+		return EvProcStop, true
+	case rawEvGoPreempt:
+		return EvGoPreempt, true
+	default:
+		return EvNone, false
+	}
+}
+
+// decodeEvents resolves raw, per-P-batched records into a single
+// chronological Event stream: it carries forward the per-P "current
+// goroutine" and per-batch timestamp base the wire format relies on
+// (events only ever encode a delta from the last one on their P), then
+// converts tracer ticks to nanoseconds and sorts by timestamp.
+//
+// Unlike internal/trace's order1005/order1007, this does not reorder events
+// that share a timestamp by goroutine-unblock causality - xtrace only needs
+// a faithful, ordered event stream for visualization, not the verified
+// happens-before order the standard library's tooling enforces.
+func decodeEvents(raw []rawEvent, ver int) ([]*Event, error) {
+	var ticksPerSec int64
+	var lastSeq, lastTs int64
+	var lastG uint64
+	var lastP int
+	lastGs := make(map[int]uint64)
+
+	var events []*Event
+	for _, r := range raw {
+		desc := rawEventDescs[r.typ]
+		if r.typ != rawEvStack {
+			if want := argNum(r.typ, ver); want != len(r.args) {
+				return nil, fmt.Errorf("%s has wrong number of arguments: want %d, got %d", desc.name, want, len(r.args))
+			}
+		}
+
+		switch r.typ {
+		case rawEvBatch:
+			lastGs[lastP] = lastG
+			lastP = int(r.args[0])
+			lastG = lastGs[lastP]
+			if ver < 1007 {
+				lastSeq = int64(r.args[1])
+				lastTs = int64(r.args[2])
+			} else {
+				lastTs = int64(r.args[1])
+			}
+		case rawEvFrequency:
+			ticksPerSec = int64(r.args[0])
+			if ticksPerSec <= 0 {
+				return nil, fmt.Errorf("bad trace tick frequency %d", ticksPerSec)
+			}
+		case rawEvTimerGoroutine, rawEvStack:
+			// Not surfaced to analyzeTrace: timer-goroutine remapping and
+			// call stacks have no corresponding Event field.
+		default:
+			var ts int64
+			argOffset := 1
+			if ver < 1007 {
+				lastSeq += int64(r.args[0])
+				ts = lastTs + int64(r.args[1])
+				argOffset = 2
+			} else {
+				ts = lastTs + int64(r.args[0])
+			}
+			lastTs = ts
+
+			var args [4]uint64
+			narg := len(r.args)
+			for i := argOffset; i < narg; i++ {
+				if i == narg-1 && desc.stack {
+					continue // trailing stack id; not surfaced
+				}
+				if idx := i - argOffset; idx < len(args) {
+					args[idx] = r.args[i]
+				}
+			}
+
+			g, p := lastG, lastP
+			switch r.typ {
+			case rawEvGoStart, rawEvGoStartLocal, rawEvGoStartLabel:
+				g = args[0]
+				lastG = g
+			case rawEvSTWStart, rawEvGCStart, rawEvGCDone, rawEvSTWDone:
+				g = 0
+			case rawEvGoEnd, rawEvGoStop, rawEvGoSched, rawEvGoPreempt,
+				rawEvGoSleep, rawEvGoBlock, rawEvGoBlockSend, rawEvGoBlockRecv,
+				rawEvGoBlockSelect, rawEvGoBlockSync, rawEvGoBlockCond, rawEvGoBlockNet,
+				rawEvGoSysBlock, rawEvGoBlockGC:
+				lastG = 0
+			case rawEvGoSysExit, rawEvGoWaiting, rawEvGoInSyscall:
+				g = args[0]
+			}
+
+			if typ, ok := eventTypeFor(r.typ); ok {
+				events = append(events, &Event{Type: typ, Ts: ts, G: g, P: p, Args: args})
+			}
+		}
+	}
+
+	if ticksPerSec == 0 {
+		return nil, fmt.Errorf("no EvFrequency event in trace")
+	}
+	if len(events) == 0 {
+		return events, nil
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Ts < events[j].Ts })
+
+	minTs := events[0].Ts
+	freq := 1e9 / float64(ticksPerSec)
+	for _, ev := range events {
+		ev.Ts = int64(float64(ev.Ts-minTs) * freq)
+	}
+	return events, nil
+}
@@ -0,0 +1,79 @@
+// Package trace is a local stand-in for golang.org/x/trace, which
+// backend.go has depended on since the project's baseline commit even
+// though no such module is published. It exists purely so this module
+// has a real, buildable target for that import (wired in via the `replace`
+// directive in go.mod) - it exposes the same Parse/Event/EventType shape
+// analyzeTrace already consumes, and decodes the actual wire format the Go
+// runtime's execution tracer (package runtime/trace) writes, rather than
+// stubbing it out. See wireformat.go for the decoder itself; this file only
+// holds the public shape analyzeTrace consumes.
+package trace
+
+import (
+	"bufio"
+	"io"
+)
+
+// EventType mirrors the subset of runtime/trace event kinds analyzeTrace
+// switches on. The trace wire format carries many more event types than
+// this (GC sweeps, heap stats, user tasks, ...); wireformat.go decodes all
+// of them to stay byte-aligned with the stream but only ever produces
+// Events for the kinds listed here, since those are all analyzeTrace uses.
+type EventType byte
+
+const (
+	EvNone EventType = iota
+	EvGoCreate
+	EvGoStart
+	EvGoEnd
+	EvGoBlockSend
+	EvGoBlockRecv
+	EvGoUnblock
+	EvGoBlockSync
+	EvGoSysCall
+	EvGoSysBlock
+	EvGoSysExit
+	EvGCStart
+	EvGCDone
+	EvGCSTWStart
+	EvGCSTWDone
+	EvProcStart
+	EvProcStop
+	EvGoPreempt
+)
+
+// Event is one entry in a parsed trace.
+type Event struct {
+	Type EventType
+	Ts   int64
+	G    uint64
+	P    int
+	Args [4]uint64
+}
+
+// ParsedTrace holds every event decoded from a trace.out file.
+type ParsedTrace struct {
+	Events []*Event
+}
+
+// Parse reads a trace.out file produced by runtime/trace.Start and decodes
+// it into the subset of events analyzeTrace understands. path is unused: it
+// exists only to match the binary-argument shape of upstream trace parsers
+// that symbolize pre-1.7 traces against the original binary. This decoder
+// accepts pre-1.7 traces but does no stack symbolication, so it never reads
+// the binary.
+func Parse(r io.Reader, path string) (ParsedTrace, error) {
+	// readRawEvents reads the stream a field (often a single byte) at a
+	// time; buffering here keeps that from costing one read syscall per
+	// field against an unbuffered source like the *os.File analyzeTrace
+	// hands in.
+	raw, ver, err := readRawEvents(bufio.NewReader(r))
+	if err != nil {
+		return ParsedTrace{}, err
+	}
+	events, err := decodeEvents(raw, ver)
+	if err != nil {
+		return ParsedTrace{}, err
+	}
+	return ParsedTrace{Events: events}, nil
+}
@@ -0,0 +1,108 @@
+package trace
+
+import (
+	"bytes"
+	"testing"
+)
+
+// putVarint appends v to buf using the trace wire format's base-128
+// little-endian varint encoding (the same scheme readVal decodes).
+func putVarint(buf *bytes.Buffer, v uint64) {
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			b |= 0x80
+		}
+		buf.WriteByte(b)
+		if v == 0 {
+			return
+		}
+	}
+}
+
+// putEvent always uses the wire format's length-prefixed argument form
+// (top two bits of the type byte = 3, i.e. narg = 4), which is valid for any real argument count and
+// keeps this test from having to special-case the 1-3 inline-argument form.
+func putEvent(buf *bytes.Buffer, typ rawEventType, args ...uint64) {
+	buf.WriteByte(byte(typ) | 0xc0)
+	var argBuf bytes.Buffer
+	for _, a := range args {
+		putVarint(&argBuf, a)
+	}
+	putVarint(buf, uint64(argBuf.Len()))
+	buf.Write(argBuf.Bytes())
+}
+
+// buildTrace assembles a minimal but well-formed go 1.21 trace: one P
+// running a Batch/Frequency header, creating and starting goroutine 2,
+// running a GC cycle, then tearing both down.
+func buildTrace(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	buf.WriteString("go 1.21 trace\x00\x00\x00")
+
+	// Each event's first argument is its timestamp delta from the previous
+	// event on the P, so the events below land at ts 1, 3, 6, 10, 15, 21, 28.
+	putEvent(&buf, rawEvBatch, 0, 0)              // p=0, base ts=0
+	putEvent(&buf, rawEvFrequency, 1_000_000_000) // 1 tick == 1ns
+	putEvent(&buf, rawEvProcStart, 1, 100)        // ts delta=1, thread=100
+	putEvent(&buf, rawEvGoCreate, 2, 2, 0, 0)     // ts delta=2, new g=2, new stack=0, creator stack=0
+	putEvent(&buf, rawEvGoStart, 3, 2, 0)         // ts delta=3, g=2, seq=0
+	putEvent(&buf, rawEvGCStart, 4, 1, 0)         // ts delta=4, seq=1, stack=0
+	putEvent(&buf, rawEvGCDone, 5)                // ts delta=5
+	putEvent(&buf, rawEvGoEnd, 6)                 // ts delta=6
+	putEvent(&buf, rawEvProcStop, 7)              // ts delta=7
+
+	return buf.Bytes()
+}
+
+func TestParseDecodesRealEvents(t *testing.T) {
+	pt, err := Parse(bytes.NewReader(buildTrace(t)), "")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(pt.Events) == 0 {
+		t.Fatal("expected at least one decoded event, got zero")
+	}
+
+	var gotCreate, gotStart, gotGCStart, gotGCDone bool
+	for _, ev := range pt.Events {
+		switch ev.Type {
+		case EvGoCreate:
+			gotCreate = true
+			if ev.Args[0] != 2 {
+				t.Errorf("GoCreate: want new goroutine id 2, got %d", ev.Args[0])
+			}
+		case EvGoStart:
+			gotStart = true
+			if ev.G != 2 {
+				t.Errorf("GoStart: want G=2, got %d", ev.G)
+			}
+		case EvGCStart:
+			gotGCStart = true
+			if ev.G != 0 {
+				t.Errorf("GCStart: want G=0, got %d", ev.G)
+			}
+		case EvGCDone:
+			gotGCDone = true
+		}
+	}
+	if !gotCreate || !gotStart || !gotGCStart || !gotGCDone {
+		t.Errorf("missing expected events: create=%v start=%v gcStart=%v gcDone=%v", gotCreate, gotStart, gotGCStart, gotGCDone)
+	}
+}
+
+func TestParseRejectsBadHeader(t *testing.T) {
+	if _, err := Parse(bytes.NewReader([]byte("not a trace file")), ""); err == nil {
+		t.Fatal("expected an error for a non-trace input")
+	}
+}
+
+func TestParseRejectsUnsupportedVersion(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("go 9.9 trace\x00\x00\x00\x00")
+	if _, err := Parse(&buf, ""); err == nil {
+		t.Fatal("expected an error for an unsupported trace version")
+	}
+}
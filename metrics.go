@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/sirupsen/logrus"
+)
+
+// log is the package-wide structured logger. It replaces the println calls
+// the server used to rely on, so `/trace` activity can actually be searched
+// and aggregated once the server is deployed.
+var log = logrus.New()
+
+var (
+	compileDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "govisualizer_compile_duration_seconds",
+		Help: "Time spent compiling submitted programs.",
+	})
+	runDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "govisualizer_run_duration_seconds",
+		Help: "Time spent executing submitted programs in the sandbox.",
+	})
+	traceParseDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "govisualizer_trace_parse_duration_seconds",
+		Help: "Time spent parsing trace.out into a graph.",
+	})
+	timeoutsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "govisualizer_timeouts_total",
+		Help: "Number of /trace requests that hit the compile or run timeout.",
+	})
+	compileFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "govisualizer_compile_failures_total",
+		Help: "Number of /trace requests that failed to compile.",
+	})
+	runtimeFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "govisualizer_runtime_failures_total",
+		Help: "Number of /trace requests whose program crashed or errored at runtime.",
+	})
+	cacheHitsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "govisualizer_cache_hits_total",
+		Help: "Number of /trace requests served from the content-addressed cache.",
+	})
+)
+
+// requestSeq generates the request_id logged for every /trace call. A
+// simple atomic counter is enough to correlate log lines for one server
+// process; it isn't meant to be globally unique across restarts.
+var requestSeq int64
+
+func nextRequestID() int64 {
+	return atomic.AddInt64(&requestSeq, 1)
+}
+
+// timeParse records how long parsing tracePath into a graph took and
+// returns the result, so callers can wrap analyzeTrace without duplicating
+// the timer bookkeeping at every call site.
+func timeParse(tracePath string) (map[string]interface{}, time.Duration, error) {
+	start := time.Now()
+	data, err := analyzeTrace(tracePath)
+	return data, time.Since(start), err
+}
@@ -0,0 +1,228 @@
+// Package sandbox runs untrusted, user-submitted Go programs in an isolated
+// environment modeled on the Go Playground: source is statically checked for
+// disallowed imports, then compiled on the host and executed inside an
+// unprivileged, network-less container with separate compile/run timeouts and
+// memory caps.
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// ErrorKind classifies why a sandboxed run failed, so callers (the HTTP
+// handler) can report a structured reason instead of a raw stderr blob.
+type ErrorKind string
+
+const (
+	BuildError   ErrorKind = "build_error"
+	RuntimeError ErrorKind = "runtime_error"
+	Timeout      ErrorKind = "timeout"
+)
+
+// Error is returned by Run when the sandboxed program fails to build, fails
+// to run, or exceeds its allotted time.
+type Error struct {
+	Kind   ErrorKind
+	Output string
+	Err    error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Kind, e.Err)
+	}
+	return string(e.Kind)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// disallowedImports are packages that grant access to the network, the
+// filesystem beyond the sandbox, other processes, or raw memory - none of
+// which a submitted snippet should ever need.
+var disallowedImports = map[string]bool{
+	"net":           true,
+	"net/http":      true,
+	"net/rpc":       true,
+	"os/exec":       true,
+	"os/user":       true,
+	"unsafe":        true,
+	"syscall":       true,
+	"plugin":        true,
+	"runtime/debug": true,
+	// "C" pulls in cgo, which compiles and links arbitrary C code; that C
+	// code can make raw syscalls directly, making every other entry in
+	// this table irrelevant.
+	"C": true,
+}
+
+// CheckImports parses src and rejects it if it imports any disallowed
+// package. It runs before compilation so a malicious submission never even
+// reaches `go build`.
+func CheckImports(src []byte) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "main.go", src, parser.ImportsOnly)
+	if err != nil {
+		return &Error{Kind: BuildError, Err: fmt.Errorf("parse error: %w", err)}
+	}
+	for _, imp := range f.Imports {
+		path := imp.Path.Value
+		path = path[1 : len(path)-1] // strip surrounding quotes
+		if disallowedImports[path] {
+			return &Error{Kind: BuildError, Err: fmt.Errorf("import %q is not allowed in the sandbox", path)}
+		}
+	}
+	return nil
+}
+
+// Config controls the resource limits applied to a sandboxed run.
+type Config struct {
+	MaxCompileTime time.Duration
+	MaxRunTime     time.Duration
+	MaxMemoryMB    int64
+}
+
+// DefaultConfig mirrors the limits the Go Playground applies to untrusted
+// submissions.
+func DefaultConfig() Config {
+	return Config{
+		MaxCompileTime: 10 * time.Second,
+		MaxRunTime:     5 * time.Second,
+		MaxMemoryMB:    256,
+	}
+}
+
+// Result holds everything analyzeTrace needs once a program has run to
+// completion inside the sandbox, plus the timing data callers use for
+// metrics and structured logging.
+type Result struct {
+	Stdout      []byte
+	TracePath   string
+	CompileTime time.Duration
+	RunTime     time.Duration
+}
+
+// Build writes code into dir and compiles it with the sandbox's disallowed
+// import check applied first, returning the path to the resulting binary.
+// It is exported separately from Run so callers that need to manage
+// execution themselves (e.g. the streaming handler, which runs the binary
+// for an open-ended duration) don't have to duplicate the compile step.
+func Build(ctx context.Context, dir string, code []byte, cfg Config) (string, error) {
+	if err := CheckImports(code); err != nil {
+		return "", err
+	}
+
+	tmpFile := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(tmpFile, code, 0644); err != nil {
+		return "", &Error{Kind: BuildError, Err: fmt.Errorf("writing source: %w", err)}
+	}
+
+	buildCtx, cancelBuild := context.WithTimeout(ctx, cfg.MaxCompileTime)
+	defer cancelBuild()
+	binPath := filepath.Join(dir, "sandboxed")
+	buildCmd := exec.CommandContext(buildCtx, "go", "build", "-o", binPath, tmpFile)
+	buildCmd.Dir = dir
+	// CGO_ENABLED=0 backstops the "C" entry in disallowedImports: even if a
+	// submission somehow got a cgo preamble past the parser check, cgo
+	// itself would be unavailable to link against.
+	buildCmd.Env = append(os.Environ(), "CGO_ENABLED=0")
+	if out, err := buildCmd.CombinedOutput(); err != nil {
+		if buildCtx.Err() == context.DeadlineExceeded {
+			return "", &Error{Kind: Timeout, Output: string(out), Err: errors.New("compile timed out")}
+		}
+		return "", &Error{Kind: BuildError, Output: string(out), Err: err}
+	}
+	return binPath, nil
+}
+
+// Run builds the Go source in code on the host (see Build), executes the
+// binary inside an unprivileged, network-isolated container, and returns the
+// combined stdout/stderr plus the
+// path to the generated trace.out. The caller is responsible for removing
+// dir once it is done reading TracePath.
+func Run(ctx context.Context, dir string, code []byte, cfg Config) (*Result, error) {
+	compileStart := time.Now()
+	binPath, err := Build(ctx, dir, code, cfg)
+	compileTime := time.Since(compileStart)
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancelRun := context.WithTimeout(ctx, cfg.MaxRunTime)
+	defer cancelRun()
+
+	runCmd, err := containerCommand(runCtx, dir, binPath, cfg)
+	if err != nil {
+		return nil, &Error{Kind: RuntimeError, Err: err}
+	}
+
+	runStart := time.Now()
+	var out bytes.Buffer
+	runCmd.Stdout = &out
+	runCmd.Stderr = &out
+	err = runCmd.Run()
+	runTime := time.Since(runStart)
+
+	if runCtx.Err() == context.DeadlineExceeded {
+		return nil, &Error{Kind: Timeout, Output: out.String(), Err: fmt.Errorf("execution exceeded %s", cfg.MaxRunTime)}
+	}
+	if err != nil {
+		return nil, &Error{Kind: RuntimeError, Output: out.String(), Err: err}
+	}
+
+	tracePath := filepath.Join(dir, "trace.out")
+	if _, statErr := os.Stat(tracePath); statErr != nil {
+		return nil, &Error{Kind: RuntimeError, Output: out.String(), Err: errors.New("trace.out was not generated")}
+	}
+
+	return &Result{Stdout: out.Bytes(), TracePath: tracePath, CompileTime: compileTime, RunTime: runTime}, nil
+}
+
+// Start prepares the command that runs binPath inside the same unprivileged,
+// network-isolated container Run uses. It does not start the process: the
+// caller starts it and handles lifecycle management (stdout, cancellation,
+// waiting). It exists for callers like the streaming handler
+// that need the program to keep running past a single request/response
+// cycle, where Run's bundled wait-and-collect behavior doesn't fit.
+func Start(ctx context.Context, dir, binPath string, cfg Config) (*exec.Cmd, error) {
+	return containerCommand(ctx, dir, binPath, cfg)
+}
+
+// containerCommand wraps binPath so it runs as an unprivileged user with no
+// network access and a capped memory budget, using Docker's --network=none.
+// There is no safe fallback: prlimit/setpriv alone cannot construct network
+// isolation, so rather than silently run submissions with full network
+// access when Docker is unavailable, containerCommand fails closed.
+func containerCommand(ctx context.Context, dir, binPath string, cfg Config) (*exec.Cmd, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return nil, fmt.Errorf("docker is required to run sandboxed programs with network isolation: %w", err)
+	}
+	// dir comes from ioutil.TempDir, which creates it 0700 and owned by the
+	// server's uid. The container runs as --user nobody, which has none of
+	// this uid's access bits, so without widening the mode nobody could not
+	// even chdir into the bind-mounted dir to exec the binary inside it - and
+	// since the submission's own job is to write trace.out into dir, "other"
+	// needs write+execute on it too, not just read+execute.
+	if err := os.Chmod(dir, 0777); err != nil {
+		return nil, fmt.Errorf("widening sandbox dir permissions for the container user: %w", err)
+	}
+	return exec.CommandContext(ctx, "docker", "run",
+		"--rm",
+		"--network=none",
+		"--memory", fmt.Sprintf("%dm", cfg.MaxMemoryMB),
+		"--pids-limit", "64",
+		"--user", "nobody",
+		"-v", fmt.Sprintf("%s:%s", dir, dir),
+		"-w", dir,
+		"golang:alpine",
+		filepath.Join(dir, "sandboxed"),
+	), nil
+}
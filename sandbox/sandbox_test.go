@@ -0,0 +1,115 @@
+package sandbox
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCheckImportsRejectsDisallowedPackages(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+	}{
+		{"net", `package main; import "net"; func main() {}`},
+		{"os/exec", `package main; import "os/exec"; func main() {}`},
+		{"syscall", `package main; import "syscall"; func main() {}`},
+		{"unsafe", `package main; import "unsafe"; func main() {}`},
+		{"cgo", `package main; import "C"; func main() {}`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := CheckImports([]byte(c.src))
+			if err == nil {
+				t.Fatalf("expected import %q to be rejected, got nil error", c.name)
+			}
+			var sbErr *Error
+			if !errors.As(err, &sbErr) {
+				t.Fatalf("expected a *sandbox.Error, got %T", err)
+			}
+			if sbErr.Kind != BuildError {
+				t.Fatalf("expected Kind %q, got %q", BuildError, sbErr.Kind)
+			}
+		})
+	}
+}
+
+func TestCheckImportsAllowsOrdinarySource(t *testing.T) {
+	src := `package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+func main() {
+	var wg sync.WaitGroup
+	fmt.Println("hello")
+	wg.Wait()
+}
+`
+	if err := CheckImports([]byte(src)); err != nil {
+		t.Fatalf("expected ordinary source to pass, got error: %v", err)
+	}
+}
+
+func TestCheckImportsRejectsUnparsableSource(t *testing.T) {
+	err := CheckImports([]byte("this is not valid go source {{{"))
+	if err == nil {
+		t.Fatal("expected an error for unparsable source, got nil")
+	}
+	var sbErr *Error
+	if !errors.As(err, &sbErr) {
+		t.Fatalf("expected a *sandbox.Error, got %T", err)
+	}
+	if sbErr.Kind != BuildError {
+		t.Fatalf("expected Kind %q, got %q", BuildError, sbErr.Kind)
+	}
+}
+
+// TestRunEndToEndWithDocker exercises the full Build+containerCommand path
+// against a real Docker daemon, rather than only unit-testing CheckImports.
+// It is the regression test for the 0700-temp-dir/--user nobody permission
+// mismatch: without the os.Chmod(dir, 0777) in containerCommand, the
+// container cannot even chdir into the bind-mounted dir and every run fails.
+func TestRunEndToEndWithDocker(t *testing.T) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker not available in this environment")
+	}
+
+	dir, err := os.MkdirTemp("", "sandbox-e2e")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	src := `package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/trace"
+)
+
+func main() {
+	f, _ := os.Create("trace.out")
+	defer f.Close()
+	trace.Start(f)
+	defer trace.Stop()
+	fmt.Println("hello from the sandbox")
+}
+`
+	result, err := Run(context.Background(), dir, []byte(src), DefaultConfig())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !strings.Contains(string(result.Stdout), "hello from the sandbox") {
+		t.Errorf("unexpected stdout: %q", result.Stdout)
+	}
+	if _, err := os.Stat(result.TracePath); err != nil {
+		t.Errorf("expected trace.out at %s: %v", result.TracePath, err)
+	}
+}
@@ -0,0 +1,135 @@
+// Package store persists analyzed traces keyed by the SHA-256 hash of the
+// source that produced them, the same content-addressing scheme the Go
+// Playground uses for its share links. A repeat submission of source
+// already in the store skips compilation entirely and returns the cached
+// graph.
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	tracesBucket  = []byte("traces")  // hash -> raw trace.out bytes
+	graphsBucket  = []byte("graphs")  // hash -> analyzed graph JSON
+	historyBucket = []byte("history") // submitted_at (big-endian uint64) -> hash
+)
+
+// Entry summarizes one cached trace for the history listing.
+type Entry struct {
+	Hash           string `json:"hash"`
+	SubmittedAt    int64  `json:"submitted_at"`
+	GoroutineCount int    `json:"goroutine_count"`
+}
+
+// Store is a small on-disk cache of analyzed traces, backed by bbolt.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open creates or opens the bbolt database at path, creating the buckets it
+// needs if they don't already exist.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening store at %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, b := range [][]byte{tracesBucket, graphsBucket, historyBucket} {
+			if _, err := tx.CreateBucketIfNotExists(b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating buckets: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error { return s.db.Close() }
+
+// Hash returns the content address for a submitted source file.
+func Hash(code []byte) string {
+	sum := sha256.Sum256(code)
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached trace bytes and analyzed graph JSON for hash, or
+// ok=false if nothing has been cached for it yet.
+func (s *Store) Get(hash string) (traceBytes, graphJSON []byte, ok bool, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		traceBytes = copyBytes(tx.Bucket(tracesBucket).Get([]byte(hash)))
+		graphJSON = copyBytes(tx.Bucket(graphsBucket).Get([]byte(hash)))
+		return nil
+	})
+	ok = traceBytes != nil && graphJSON != nil
+	return
+}
+
+// Put caches the raw trace and its analyzed graph under hash, and records
+// an Entry in the history index so Recent can list it.
+func (s *Store) Put(hash string, traceBytes, graphJSON []byte, goroutineCount int) error {
+	submittedAt := time.Now().Unix()
+	entry := Entry{Hash: hash, SubmittedAt: submittedAt, GoroutineCount: goroutineCount}
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("marshaling history entry: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(tracesBucket).Put([]byte(hash), traceBytes); err != nil {
+			return err
+		}
+		if err := tx.Bucket(graphsBucket).Put([]byte(hash), graphJSON); err != nil {
+			return err
+		}
+		return tx.Bucket(historyBucket).Put(historyKey(submittedAt, hash), entryJSON)
+	})
+}
+
+// Recent returns up to limit Entries, most recently submitted first.
+func (s *Store) Recent(limit int) ([]Entry, error) {
+	var entries []Entry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(historyBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(entries) < limit; k, v = c.Prev() {
+			var e Entry
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// historyKey orders entries by submission time so the history bucket's
+// cursor can walk them chronologically; the hash is appended to keep keys
+// unique when two submissions land in the same second.
+func historyKey(submittedAt int64, hash string) []byte {
+	key := make([]byte, 8+len(hash))
+	binary.BigEndian.PutUint64(key[:8], uint64(submittedAt))
+	copy(key[8:], hash)
+	return key
+}
+
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	out := make([]byte, len(b))
+	copy(out, b)
+	return out
+}
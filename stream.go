@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Shuhaib123/ToolProject/sandbox"
+)
+
+// streamPollInterval is how often streamTraceHandler checks trace.out for
+// new bytes while the sandboxed program is still running.
+const streamPollInterval = 250 * time.Millisecond
+
+// minReparseGrowthBytes is the minimum growth in trace.out's size since the
+// last successful parse before streamTraceHandler will pay for another full
+// parseGraph pass. xtrace's decoder makes a single sequential pass over the
+// whole file to rebuild its per-P batch state (current G, P, sequence
+// number, clock), so it has no way to resume from where the last parse left
+// off - every tick necessarily reparses from byte 0. Gating on a minimum
+// growth, rather than reparsing on every tick the file merely changed size,
+// keeps that full-file cost from compounding into ~maxStreamDuration /
+// streamPollInterval reparses of an ever-larger file for traces that grow in
+// small increments; a genuinely resumable incremental decoder would need
+// xtrace to expose and accept that per-P state across calls, which is out of
+// scope here.
+const minReparseGrowthBytes = 4096
+
+// maxStreamDuration bounds how long a single /trace/stream session may run
+// the sandboxed program, independent of cfg.MaxRunTime (which only applies
+// to the batch handler) and of how long the client keeps its connection
+// open. Without this, a client could hold an EventSource open indefinitely
+// and get an unbounded-duration sandboxed process.
+const maxStreamDuration = 30 * time.Second
+
+// maxConcurrentStreams caps how many /trace/stream sessions may run their
+// sandboxed program at once, so maxStreamDuration's "generous" allowance
+// can't be multiplied into a resource-exhaustion problem by many concurrent
+// clients.
+const maxConcurrentStreams = 8
+
+var streamSlots = make(chan struct{}, maxConcurrentStreams)
+
+// delta is one incremental update pushed to the browser as the sandboxed
+// program runs. op mirrors the graph mutations the frontend already knows
+// how to apply from the batch `/trace` response.
+type delta struct {
+	Op   string `json:"op"` // "addNode" | "addEdge" | "updateState"
+	Node *Node  `json:"node,omitempty"`
+	Edge *Edge  `json:"edge,omitempty"`
+}
+
+// streamTraceHandler compiles and runs the submitted program the same way
+// traceHandler does, but instead of waiting for it to exit, it tails
+// trace.out as the program runs and pushes graph deltas to the browser over
+// Server-Sent Events. This is what makes long-running or server-style
+// programs visualizable at all, since traceHandler requires the process to
+// terminate before trace.out can be parsed.
+//
+// EventSource only supports GET, so the source is passed base64-encoded in
+// the `code` query parameter rather than in the request body.
+func streamTraceHandler(w http.ResponseWriter, r *http.Request) {
+	encoded := r.URL.Query().Get("code")
+	code, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		http.Error(w, "code must be base64-encoded", 400)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", 500)
+		return
+	}
+
+	select {
+	case streamSlots <- struct{}{}:
+		defer func() { <-streamSlots }()
+	default:
+		http.Error(w, "too many concurrent trace streams; try again shortly", http.StatusServiceUnavailable)
+		return
+	}
+
+	dir, _ := ioutil.TempDir("", "gtrace-stream")
+	defer os.RemoveAll(dir)
+
+	cfg := sandbox.DefaultConfig()
+	binPath, err := sandbox.Build(r.Context(), dir, code, cfg)
+	if err != nil {
+		writeSSE(w, flusher, "error", map[string]string{"error": err.Error()})
+		return
+	}
+
+	// maxStreamDuration, not the client connection's lifetime, bounds how
+	// long the sandboxed program may run: a client can otherwise keep an
+	// EventSource open indefinitely.
+	runCtx, cancelRun := context.WithTimeout(r.Context(), maxStreamDuration)
+	defer cancelRun()
+
+	runCmd, err := sandbox.Start(runCtx, dir, binPath, cfg)
+	if err != nil {
+		writeSSE(w, flusher, "error", map[string]string{"error": err.Error()})
+		return
+	}
+	if err := runCmd.Start(); err != nil {
+		writeSSE(w, flusher, "error", map[string]string{"error": err.Error()})
+		return
+	}
+	done := make(chan error, 1)
+	go func() { done <- runCmd.Wait() }()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	tracePath := dir + "/trace.out"
+	sent := newSeenSet()
+	var lastSize, lastParsedSize int64
+
+	ticker := time.NewTicker(streamPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case runErr := <-done:
+			// Drain any bytes trace.out picked up between the last tick
+			// and the process exiting, then tell the client the stream is
+			// over instead of leaving it polling a file that will never
+			// change again.
+			if graph, parseErr := parseGraph(tracePath); parseErr == nil {
+				sendDeltas(w, flusher, graph, sent)
+			}
+			if runCtx.Err() == context.DeadlineExceeded {
+				writeSSE(w, flusher, "error", map[string]string{"error": fmt.Sprintf("execution exceeded %s", maxStreamDuration)})
+			} else if runErr != nil {
+				writeSSE(w, flusher, "error", map[string]string{"error": runErr.Error()})
+			} else {
+				writeSSE(w, flusher, "done", map[string]string{})
+			}
+			return
+		case <-ticker.C:
+			info, statErr := os.Stat(tracePath)
+			if statErr != nil || info.Size() == lastSize {
+				continue
+			}
+			lastSize = info.Size()
+			if lastSize-lastParsedSize < minReparseGrowthBytes {
+				continue
+			}
+
+			graph, parseErr := parseGraph(tracePath)
+			if parseErr != nil {
+				// trace.out is still being written to; a partial file
+				// often fails to parse. Wait for the next tick.
+				continue
+			}
+			lastParsedSize = lastSize
+			sendDeltas(w, flusher, graph, sent)
+		}
+	}
+}
+
+// parseGraph runs the same event-to-graph pipeline analyzeTrace uses,
+// without the wrapping map that the batch JSON response needs.
+func parseGraph(tracePath string) (Graph, error) {
+	data, err := analyzeTrace(tracePath)
+	if err != nil {
+		return Graph{}, err
+	}
+	graph, ok := data["trace"].(Graph)
+	if !ok {
+		return Graph{}, fmt.Errorf("unexpected graph shape")
+	}
+	return graph, nil
+}
+
+// seenSet remembers which nodes and edges have already been pushed to the
+// client, so each poll only emits what changed since the last one.
+type seenSet struct {
+	nodes map[uint64]string // node ID -> last sent state
+	edges map[string]bool
+}
+
+func newSeenSet() *seenSet {
+	return &seenSet{nodes: make(map[uint64]string), edges: make(map[string]bool)}
+}
+
+func edgeKey(e Edge) string {
+	return fmt.Sprintf("%d-%d-%s-%s", e.From, e.To, e.Kind, e.Label)
+}
+
+func sendDeltas(w http.ResponseWriter, flusher http.Flusher, g Graph, sent *seenSet) {
+	for _, n := range g.Nodes {
+		n := n
+		if prevState, ok := sent.nodes[n.ID]; !ok {
+			sent.nodes[n.ID] = n.State
+			writeSSE(w, flusher, "delta", delta{Op: "addNode", Node: &n})
+		} else if prevState != n.State {
+			sent.nodes[n.ID] = n.State
+			writeSSE(w, flusher, "delta", delta{Op: "updateState", Node: &n})
+		}
+	}
+	for _, e := range g.Edges {
+		e := e
+		key := edgeKey(e)
+		if !sent.edges[key] {
+			sent.edges[key] = true
+			writeSSE(w, flusher, "delta", delta{Op: "addEdge", Edge: &e})
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, flusher http.Flusher, event string, payload interface{}) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+	flusher.Flush()
+}